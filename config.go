@@ -0,0 +1,153 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the top-level YAML configuration for repo-digest. It
+// declares every repository to digest in one invocation, along with
+// any per-repo overrides to the default ignore patterns and PR size
+// thresholds.
+type Config struct {
+	Repos []RepoConfig `json:"repos"`
+}
+
+// RepoConfig configures the digest for a single repository.
+type RepoConfig struct {
+	// Repo is the repository identifier passed to the selected forge,
+	// e.g. "cockroachdb/cockroach".
+	Repo string `json:"repo"`
+	// Forge selects the forge for this repo (see NewForge), e.g.
+	// "gitlab", overriding the --forge flag and the GitHub default.
+	// The forge is not detected from the repo string itself.
+	Forge string `json:"forge,omitempty"`
+	// Ignore lists additional filename regexes to exclude from this
+	// repo's digests, on top of the built-in ignoreRegexp defaults.
+	Ignore []string `json:"ignore,omitempty"`
+	// Thresholds overrides tinyPR...largePR for this repo's pull
+	// requests.
+	Thresholds *SizeThresholds `json:"thresholds,omitempty"`
+	// Tags are attached to every pull request digested from this
+	// repo, for downstream grouping.
+	Tags []string `json:"tags,omitempty"`
+
+	ignoreRegexps []*regexp.Regexp
+}
+
+// SizeThresholds overrides the tinyPR/smallPR/mediumPR/largePR
+// constants for a single repo, so a docs-heavy repo and a systems
+// repo can each tune what counts as "large". Zero fields fall back to
+// the package defaults.
+type SizeThresholds struct {
+	Tiny   int `json:"tiny,omitempty"`
+	Small  int `json:"small,omitempty"`
+	Medium int `json:"medium,omitempty"`
+	Large  int `json:"large,omitempty"`
+}
+
+// thresholds is the resolved (non-pointer, fully-defaulted) form of
+// SizeThresholds that PullRequest.Class reads from.
+type thresholds struct {
+	tiny, small, medium, large int
+}
+
+var defaultThresholds = thresholds{tiny: tinyPR, small: smallPR, medium: mediumPR, large: largePR}
+
+// LoadConfig reads and parses the YAML config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %s", path, err)
+	}
+	for i := range cfg.Repos {
+		rc := &cfg.Repos[i]
+		for _, pattern := range rc.Ignore {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("repo %s: invalid ignore pattern %q: %s", rc.Repo, pattern, err)
+			}
+			rc.ignoreRegexps = append(rc.ignoreRegexps, re)
+		}
+	}
+	return &cfg, nil
+}
+
+// RepoConfig looks up the configuration declared for repo, falling
+// back to an empty RepoConfig (and so the default ignore patterns and
+// thresholds) if cfg is nil or doesn't declare it explicitly. This
+// lets repo-digest run against an undeclared repo exactly as it did
+// before config files existed.
+func (cfg *Config) RepoConfig(repo string) *RepoConfig {
+	if cfg == nil {
+		return &RepoConfig{Repo: repo}
+	}
+	for i := range cfg.Repos {
+		if cfg.Repos[i].Repo == repo {
+			return &cfg.Repos[i]
+		}
+	}
+	return &RepoConfig{Repo: repo}
+}
+
+// skipFile reports whether f should be excluded from the digest,
+// consulting both the built-in ignoreRegexp defaults and rc's
+// per-repo ignore patterns.
+func skipFile(rc *RepoConfig, f string) bool {
+	for _, ire := range ignoreRegexp {
+		if ire.MatchString(f) {
+			return true
+		}
+	}
+	for _, ire := range rc.ignoreRegexps {
+		if ire.MatchString(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeThresholds returns rc's size thresholds, falling back to the
+// package defaults for any class left unset.
+func (rc *RepoConfig) sizeThresholds() thresholds {
+	t := defaultThresholds
+	if rc.Thresholds == nil {
+		return t
+	}
+	if rc.Thresholds.Tiny != 0 {
+		t.tiny = rc.Thresholds.Tiny
+	}
+	if rc.Thresholds.Small != 0 {
+		t.small = rc.Thresholds.Small
+	}
+	if rc.Thresholds.Medium != 0 {
+		t.medium = rc.Thresholds.Medium
+	}
+	if rc.Thresholds.Large != 0 {
+		t.large = rc.Thresholds.Large
+	}
+	return t
+}
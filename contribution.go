@@ -0,0 +1,77 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"io"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Contribution is a machine-readable record of a single pull request,
+// suitable for feeding an "I'm working on" page or a contribution
+// tracker, as an alternative to the human-readable HTML digest.
+type Contribution struct {
+	URLs          []string `json:"urls"`
+	Tags          []string `json:"tags,omitempty"`
+	SponsoredBy   string   `json:"sponsored-by,omitempty"`
+	SubmittedAt   string   `json:"submitted-at"`
+	LastUpdatedAt string   `json:"last-updated-at"`
+	Status        string   `json:"status"`
+}
+
+// NewContribution builds a Contribution record from pr.
+func NewContribution(pr *PullRequest) *Contribution {
+	status := pr.State
+	if pr.Merged {
+		status = "merged"
+	}
+	return &Contribution{
+		URLs:          []string{pr.HtmlURL},
+		Tags:          pr.Tags,
+		SponsoredBy:   sponsoredBy(pr),
+		SubmittedAt:   pr.CreatedAt,
+		LastUpdatedAt: pr.Updatedat,
+		Status:        status,
+	}
+}
+
+// sponsoredBy derives a sponsoring organization from the domain of
+// the merge commit's Signed-off-by trailer, if any.
+func sponsoredBy(pr *PullRequest) string {
+	for _, v := range pr.Trailers["Signed-off-by"] {
+		if domain := emailDomain(v); domain != "" {
+			return domain
+		}
+	}
+	return ""
+}
+
+// WriteContributions writes prs as a YAML document of Contribution
+// records to w.
+func WriteContributions(w io.Writer, prs []*PullRequest) error {
+	contributions := make([]*Contribution, len(prs))
+	for i, pr := range prs {
+		contributions[i] = NewContribution(pr)
+	}
+	data, err := yaml.Marshal(contributions)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import "fmt"
+
+// Forge abstracts over the pull-request hosting systems repo-digest
+// knows how to query. Each implementation is responsible for
+// translating its own API's JSON shape into the forge-neutral
+// PullRequest, File and User structs so the rest of the digest
+// pipeline never has to know which forge it's talking to.
+type Forge interface {
+	// FetchPullRequests returns the open and closed pull requests for
+	// c.Repo, restricted to those opened or closed since c.FetchSince.
+	FetchPullRequests(c *Context) (open, closed []*PullRequest, err error)
+	// FetchDetails fills in pr.Files and any other fields not present
+	// in the summary listing returned by FetchPullRequests.
+	FetchDetails(c *Context, pr *PullRequest) error
+}
+
+// forgeEntry associates a forge's name with a constructor for its
+// Forge implementation.
+type forgeEntry struct {
+	name     string
+	newForge func() Forge
+}
+
+var forgeRegistry = []forgeEntry{
+	{"github", func() Forge { return &githubForge{} }},
+	{"gitlab", func() Forge { return &gitlabForge{} }},
+	{"gerrit", func() Forge { return &gerritForge{} }},
+	{"gitea", func() Forge { return &giteaForge{} }},
+}
+
+// NewForge selects a Forge implementation for c. The repo's own
+// RepoConfig.Forge (set in the YAML config) takes precedence, since
+// it's the only way to disambiguate repos in a multi-repo,
+// multi-forge config; c.ForgeName, set by the --forge flag, is next;
+// GitHub is assumed if neither is set.
+func NewForge(c *Context) (Forge, error) {
+	if rc := c.Config.RepoConfig(c.Repo); rc.Forge != "" {
+		return newForgeByName(rc.Forge)
+	}
+	if c.ForgeName != "" {
+		return newForgeByName(c.ForgeName)
+	}
+	return &githubForge{}, nil
+}
+
+func newForgeByName(name string) (Forge, error) {
+	for _, e := range forgeRegistry {
+		if e.name == name {
+			return e.newForge(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown forge %q", name)
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import "testing"
+
+// forgeTypeName returns the forgeRegistry name for forge's concrete
+// type, so tests can assert on NewForge's result without exposing new
+// API just for testing.
+func forgeTypeName(forge Forge) string {
+	switch forge.(type) {
+	case *githubForge:
+		return "github"
+	case *gitlabForge:
+		return "gitlab"
+	case *gerritForge:
+		return "gerrit"
+	case *giteaForge:
+		return "gitea"
+	default:
+		return "unknown"
+	}
+}
+
+func TestNewForgePriority(t *testing.T) {
+	testCases := []struct {
+		name      string
+		repo      string
+		forgeName string
+		config    *Config
+		want      string
+	}{
+		{name: "default is github", repo: "cockroachdb/cockroach", want: "github"},
+		{name: "ForgeName is honored", repo: "cockroachdb/cockroach", forgeName: "gitlab", want: "gitlab"},
+		{
+			name: "RepoConfig.Forge takes precedence over ForgeName",
+			repo: "cockroachdb/cockroach",
+			config: &Config{Repos: []RepoConfig{
+				{Repo: "cockroachdb/cockroach", Forge: "gerrit"},
+			}},
+			forgeName: "gitlab",
+			want:      "gerrit",
+		},
+		{
+			name: "RepoConfig.Forge is ignored for a different repo",
+			repo: "cockroachdb/cockroach",
+			config: &Config{Repos: []RepoConfig{
+				{Repo: "other/repo", Forge: "gerrit"},
+			}},
+			want: "github",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Context{Repo: tc.repo, ForgeName: tc.forgeName, Config: tc.config}
+			forge, err := NewForge(c)
+			if err != nil {
+				t.Fatalf("NewForge() returned error: %s", err)
+			}
+			if got := forgeTypeName(forge); got != tc.want {
+				t.Errorf("NewForge() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewForgeUnknownName(t *testing.T) {
+	c := &Context{Repo: "cockroachdb/cockroach", ForgeName: "bitbucket"}
+	if _, err := NewForge(c); err == nil {
+		t.Fatal("NewForge() with an unknown forge name returned no error")
+	}
+}
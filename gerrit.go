@@ -0,0 +1,245 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// gerritMagicPrefix is Gerrit's XSSI-protection line, prepended to
+// every JSON response from its REST API. It must be stripped before
+// the body can be unmarshaled.
+var gerritMagicPrefix = []byte(")]}'")
+
+// gerritTimeLayout is the timestamp format Gerrit uses in its JSON
+// responses, e.g. "2016-03-01 12:34:56.000000000".
+const gerritTimeLayout = "2006-01-02 15:04:05.000000000"
+
+// GerritTime unmarshals Gerrit's non-RFC3339 timestamp format into a
+// time.Time. It embeds time.Time, so it also defines its own
+// UnmarshalJSON: otherwise encoding/json would use the promoted
+// time.Time.UnmarshalJSON (which expects RFC3339) rather than
+// UnmarshalText.
+type GerritTime struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler, so Gerrit's
+// "2006-01-02 15:04:05.000000000" layout is used instead of the
+// time.Time default of RFC3339.
+func (t *GerritTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(gerritTimeLayout, s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for callers
+// outside encoding/json that decode Gerrit timestamps as bare text.
+func (t *GerritTime) UnmarshalText(text []byte) error {
+	parsed, err := time.Parse(gerritTimeLayout, string(text))
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// httpGetGerritJSON issues a cached, conditional GET to url and
+// unmarshals the response into v, stripping Gerrit's ")]}'"
+// XSSI-protection prefix first.
+func httpGetGerritJSON(c *Context, url string, v interface{}) error {
+	cache := getCache(c)
+	entry, _ := cache.get(url)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	cache.applyValidators(req, entry)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
+		return fmt.Errorf("gerrit request to %s failed: %s", url, resp.Status)
+	}
+	respBody = bytes.TrimPrefix(respBody, gerritMagicPrefix)
+	body, err := cache.handleResponse(resp, url, entry, respBody)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// gerritForge implements Forge against the Gerrit Code Review REST
+// API. Unlike the other forges, a single Gerrit host serves many
+// projects, so c.Repo is expected to be the full URL of one project on
+// that host, e.g. "https://gerrit.example.com/my/project", rather than
+// the bare "org/project" shorthand the other forges use.
+type gerritForge struct{}
+
+// gerritHostAndProject splits repo into the Gerrit host (scheme plus
+// authority, suitable for building further API URLs) and the project
+// path beneath it, e.g. "https://gerrit.example.com/my/project" splits
+// into "https://gerrit.example.com" and "my/project".
+func gerritHostAndProject(repo string) (host, project string, err error) {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return "", "", err
+	}
+	project = strings.Trim(u.Path, "/")
+	if u.Scheme == "" || u.Host == "" || project == "" {
+		return "", "", fmt.Errorf("gerrit repo %q must be a full project URL, e.g. https://gerrit.example.com/my/project", repo)
+	}
+	u.Path = ""
+	return u.String(), project, nil
+}
+
+// gerritChange is Gerrit's JSON shape for a single change, as
+// returned by /changes/?q=....
+type gerritChange struct {
+	ID              string     `json:"id"`
+	Project         string     `json:"project"`
+	ChangeID        string     `json:"change_id"`
+	Number          int        `json:"_number"`
+	Subject         string     `json:"subject"`
+	Status          string     `json:"status"`
+	Created         GerritTime `json:"created"`
+	Updated         GerritTime `json:"updated"`
+	Submitted       GerritTime `json:"submitted"`
+	CurrentRevision string     `json:"current_revision"`
+	Owner           struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	} `json:"owner"`
+}
+
+func (ch *gerritChange) toPullRequest(host string) *PullRequest {
+	pr := &PullRequest{
+		URL:       fmt.Sprintf("%s/changes/%s", host, ch.ID),
+		HtmlURL:   fmt.Sprintf("%s/c/%s/+/%d", host, ch.Project, ch.Number),
+		Number:    ch.Number,
+		Title:     ch.Subject,
+		CreatedAt: ch.Created.Format(time.RFC3339),
+		Updatedat: ch.Updated.Format(time.RFC3339),
+		User: User{
+			Login: ch.Owner.Username,
+			Name:  ch.Owner.Name,
+			Email: ch.Owner.Email,
+		},
+	}
+	switch ch.Status {
+	case "NEW":
+		pr.State = "open"
+	case "MERGED":
+		pr.State = "closed"
+		pr.Merged = true
+		pr.ClosedAt = ch.Submitted.Format(time.RFC3339)
+		pr.MergedAt = pr.ClosedAt
+		pr.MergeCommitSHA = ch.CurrentRevision
+	case "ABANDONED":
+		pr.State = "closed"
+		pr.ClosedAt = ch.Updated.Format(time.RFC3339)
+	}
+	return pr
+}
+
+// FetchPullRequests lists changes for the Gerrit project in c.Repo,
+// querying open and (separately) merged/abandoned changes since
+// c.FetchSince, since Gerrit doesn't sort its default query by age.
+func (f *gerritForge) FetchPullRequests(c *Context) ([]*PullRequest, []*PullRequest, error) {
+	host, project, err := gerritHostAndProject(c.Repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	open, closed := []*PullRequest{}, []*PullRequest{}
+	since := c.FetchSince.Format("2006-01-02")
+	for _, q := range []string{"status:open", "status:closed"} {
+		queryURL := fmt.Sprintf("%s/changes/?q=%s+project:%s+since:%s", host, q, url.QueryEscape(project), since)
+		var changes []*gerritChange
+		if err := httpGetGerritJSON(c, queryURL, &changes); err != nil {
+			return nil, nil, err
+		}
+		for _, ch := range changes {
+			pr := ch.toPullRequest(host)
+			if pr.State == "open" {
+				open = append(open, pr)
+			} else {
+				closed = append(closed, pr)
+			}
+		}
+	}
+	return open, closed, nil
+}
+
+// FetchDetails fetches the files touched by a Gerrit change's current
+// revision.
+func (f *gerritForge) FetchDetails(c *Context, pr *PullRequest) error {
+	var files map[string]struct {
+		LinesInserted int    `json:"lines_inserted"`
+		LinesDeleted  int    `json:"lines_deleted"`
+		Status        string `json:"status"`
+	}
+	filesURL := fmt.Sprintf("%s/revisions/current/files", pr.URL)
+	if err := httpGetGerritJSON(c, filesURL, &files); err != nil {
+		return err
+	}
+	pr.Files = make([]*File, 0, len(files))
+	for name, f := range files {
+		if name == "/COMMIT_MSG" {
+			continue
+		}
+		status := "modified"
+		switch f.Status {
+		case "A":
+			status = "added"
+		case "D":
+			status = "removed"
+		case "R":
+			status = "renamed"
+		}
+		pr.Files = append(pr.Files, &File{
+			Filename:  name,
+			Status:    status,
+			Additions: f.LinesInserted,
+			Deletions: f.LinesDeleted,
+			Changes:   f.LinesInserted + f.LinesDeleted,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGerritTimeUnmarshalJSON(t *testing.T) {
+	var gt GerritTime
+	if err := json.Unmarshal([]byte(`"2016-03-01 12:34:56.000000000"`), &gt); err != nil {
+		t.Fatalf("UnmarshalJSON returned error: %s", err)
+	}
+	want := time.Date(2016, time.March, 1, 12, 34, 56, 0, time.UTC)
+	if !gt.Time.Equal(want) {
+		t.Errorf("got %s, want %s", gt.Time, want)
+	}
+}
+
+func TestGerritHostAndProject(t *testing.T) {
+	host, project, err := gerritHostAndProject("https://gerrit.example.com/my/project")
+	if err != nil {
+		t.Fatalf("gerritHostAndProject returned error: %s", err)
+	}
+	if host != "https://gerrit.example.com" {
+		t.Errorf("host = %q, want %q", host, "https://gerrit.example.com")
+	}
+	if project != "my/project" {
+		t.Errorf("project = %q, want %q", project, "my/project")
+	}
+}
+
+func TestGerritHostAndProjectRejectsBareRepo(t *testing.T) {
+	if _, _, err := gerritHostAndProject("cockroachdb/cockroach"); err == nil {
+		t.Fatal("gerritHostAndProject(\"cockroachdb/cockroach\") returned no error")
+	}
+}
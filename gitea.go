@@ -0,0 +1,136 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// giteaAPI is the default Gitea instance this forge talks to. Unlike
+// GitHub and GitLab, Gitea is commonly self-hosted; c.GiteaHost
+// overrides this when set.
+const giteaAPI = "https://gitea.com/api/v1/"
+
+// giteaForge implements Forge against the Gitea REST API.
+type giteaForge struct{}
+
+// giteaPullRequest is Gitea's JSON shape for a single pull request,
+// as returned by /repos/:owner/:repo/pulls.
+type giteaPullRequest struct {
+	Number    int    `json:"number"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	State     string `json:"state"`
+	HTMLURL   string `json:"html_url"`
+	Merged    bool   `json:"merged"`
+	MergedAt  string `json:"merged_at"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	ClosedAt  string `json:"closed_at"`
+	MergeBase string `json:"merge_base"`
+	User      struct {
+		Login     string `json:"login"`
+		FullName  string `json:"full_name"`
+		AvatarURL string `json:"avatar_url"`
+		Email     string `json:"email"`
+	} `json:"user"`
+}
+
+func (gpr *giteaPullRequest) toPullRequest() *PullRequest {
+	return &PullRequest{
+		HtmlURL:   gpr.HTMLURL,
+		Number:    gpr.Number,
+		State:     gpr.State,
+		Title:     gpr.Title,
+		Body:      gpr.Body,
+		CreatedAt: gpr.CreatedAt,
+		Updatedat: gpr.UpdatedAt,
+		ClosedAt:  gpr.ClosedAt,
+		MergedAt:  gpr.MergedAt,
+		Merged:    gpr.Merged,
+		User: User{
+			Login:     gpr.User.Login,
+			Name:      gpr.User.FullName,
+			AvatarURL: gpr.User.AvatarURL,
+			Email:     gpr.User.Email,
+		},
+	}
+}
+
+// giteaAPIBase returns the Gitea API root for c, preferring
+// c.GiteaHost (a self-hosted instance) and falling back to
+// gitea.com.
+func giteaAPIBase(c *Context) string {
+	if c.GiteaHost != "" {
+		return c.GiteaHost + "/api/v1/"
+	}
+	return giteaAPI
+}
+
+// FetchPullRequests lists all pull requests for c.Repo ("owner/repo"),
+// paging until it reaches one closed before c.FetchSince.
+func (f *giteaForge) FetchPullRequests(c *Context) ([]*PullRequest, []*PullRequest, error) {
+	apiBase := giteaAPIBase(c)
+	reqURL := fmt.Sprintf("%srepos/%s/pulls?state=all&sort=recentupdate", apiBase, c.Repo)
+	open, closed := []*PullRequest{}, []*PullRequest{}
+	var err error
+	var done bool
+	for len(reqURL) > 0 && !done {
+		fetched := []*giteaPullRequest{}
+		reqURL, err = fetchURLCached(c, reqURL, &fetched)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, gpr := range fetched {
+			pr := gpr.toPullRequest()
+			pr.URL = fmt.Sprintf("%srepos/%s/pulls/%d", apiBase, c.Repo, pr.Number)
+			if pr.State == "open" {
+				t, err := time.Parse(time.RFC3339, pr.CreatedAt)
+				if err != nil {
+					return nil, nil, err
+				}
+				if c.FetchSince.Before(t) {
+					open = append(open, pr)
+				}
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, pr.ClosedAt)
+			if err != nil {
+				return nil, nil, err
+			}
+			if t.Before(c.FetchSince) {
+				done = true
+				break
+			}
+			closed = append(closed, pr)
+		}
+	}
+	return open, closed, nil
+}
+
+// FetchDetails fetches the files changed by a Gitea pull request.
+func (f *giteaForge) FetchDetails(c *Context, pr *PullRequest) error {
+	apiBase := giteaAPIBase(c)
+	filesURL := fmt.Sprintf("%srepos/%s/pulls/%d/files", apiBase, c.Repo, pr.Number)
+	fetched := []*File{}
+	if _, err := fetchURLCached(c, filesURL, &fetched); err != nil {
+		return err
+	}
+	pr.Files = fetched
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const githubAPI = "https://api.github.com/"
+
+// githubForge implements Forge against the GitHub REST API. The
+// PullRequest, File and User structs already mirror GitHub's JSON
+// shape, so no conversion step is required here; other forges convert
+// their own shapes into these structs explicitly.
+type githubForge struct{}
+
+// FetchPullRequests lists all pull requests for c.Repo, paging
+// through the GitHub API until it reaches one closed before
+// c.FetchSince.
+func (f *githubForge) FetchPullRequests(c *Context) ([]*PullRequest, []*PullRequest, error) {
+	url := fmt.Sprintf("%srepos/%s/pulls?state=all&sort=updated&direction=desc", githubAPI, c.Repo)
+	open, closed := []*PullRequest{}, []*PullRequest{}
+	var err error
+	var done bool
+	for len(url) > 0 && !done {
+		fetched := []*PullRequest{}
+		url, err = fetchURLCached(c, url, &fetched)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, pr := range fetched {
+			var date string
+			switch pr.State {
+			case "open":
+				date = pr.CreatedAt
+			case "closed":
+				date = pr.ClosedAt
+			default:
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, date)
+			if err != nil {
+				return nil, nil, err
+			}
+			if pr.State == "open" {
+				if c.FetchSince.Before(t) {
+					open = append(open, pr)
+				}
+			} else {
+				if t.Before(c.FetchSince) {
+					done = true
+					break
+				}
+				closed = append(closed, pr)
+			}
+		}
+	}
+	return open, closed, nil
+}
+
+// FetchDetails fetches the detailed pull request info, the list of
+// changed files, the PR's labels, and (for a merged PR) the trailers
+// in its merge commit message.
+func (f *githubForge) FetchDetails(c *Context, pr *PullRequest) error {
+	if _, err := fetchURLCached(c, pr.URL, pr); err != nil {
+		return err
+	}
+	if _, err := fetchURLCached(c, pr.URL+"/files", &pr.Files); err != nil {
+		return err
+	}
+	if err := f.fetchLabels(c, pr); err != nil {
+		return err
+	}
+	if pr.Merged && pr.MergeCommitSHA != "" {
+		if err := f.fetchTrailers(c, pr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchLabels populates pr.Labels from GitHub's issue labels endpoint
+// (pull requests are issues, as far as labels are concerned).
+func (f *githubForge) fetchLabels(c *Context, pr *PullRequest) error {
+	labelsURL := fmt.Sprintf("%srepos/%s/issues/%d/labels", githubAPI, c.Repo, pr.Number)
+	var labels []struct {
+		Name string `json:"name"`
+	}
+	if _, err := fetchURLCached(c, labelsURL, &labels); err != nil {
+		return err
+	}
+	pr.Labels = make([]string, len(labels))
+	for i, l := range labels {
+		pr.Labels[i] = l.Name
+	}
+	return nil
+}
+
+// fetchTrailers fetches pr's merge commit and parses its message for
+// conventional trailers.
+func (f *githubForge) fetchTrailers(c *Context, pr *PullRequest) error {
+	var commit struct {
+		Commit struct {
+			Message string `json:"message"`
+		} `json:"commit"`
+	}
+	commitURL := fmt.Sprintf("%srepos/%s/commits/%s", githubAPI, c.Repo, pr.MergeCommitSHA)
+	if _, err := fetchURLCached(c, commitURL, &commit); err != nil {
+		return err
+	}
+	pr.Trailers = parseTrailers(commit.Commit.Message)
+	return nil
+}
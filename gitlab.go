@@ -0,0 +1,161 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const gitlabAPI = "https://gitlab.com/api/v4/"
+
+// gitlabForge implements Forge against the GitLab merge requests API.
+type gitlabForge struct{}
+
+// gitlabMergeRequest is GitLab's JSON shape for a single merge
+// request, as returned by /projects/:id/merge_requests.
+type gitlabMergeRequest struct {
+	IID            int        `json:"iid"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	State          string     `json:"state"`
+	CreatedAt      string     `json:"created_at"`
+	UpdatedAt      string     `json:"updated_at"`
+	ClosedAt       string     `json:"closed_at"`
+	MergedAt       string     `json:"merged_at"`
+	MergeCommitSHA string     `json:"merge_commit_sha"`
+	WebURL         string     `json:"web_url"`
+	Author         gitlabUser `json:"author"`
+}
+
+type gitlabUser struct {
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+	WebURL    string `json:"web_url"`
+}
+
+// toPullRequest converts a GitLab merge request into the
+// forge-neutral PullRequest shape.
+func (mr *gitlabMergeRequest) toPullRequest() *PullRequest {
+	state := mr.State
+	if state == "opened" {
+		state = "open"
+	}
+	return &PullRequest{
+		HtmlURL:        mr.WebURL,
+		Number:         mr.IID,
+		State:          state,
+		Title:          mr.Title,
+		Body:           mr.Description,
+		CreatedAt:      mr.CreatedAt,
+		Updatedat:      mr.UpdatedAt,
+		ClosedAt:       mr.ClosedAt,
+		MergedAt:       mr.MergedAt,
+		MergeCommitSHA: mr.MergeCommitSHA,
+		Merged:         mr.State == "merged",
+		User: User{
+			Login:     mr.Author.Username,
+			Name:      mr.Author.Name,
+			AvatarURL: mr.Author.AvatarURL,
+			HtmlURL:   mr.Author.WebURL,
+		},
+	}
+}
+
+// FetchPullRequests lists all merge requests for c.Repo (an
+// "org/project" path), paging until it reaches one closed or merged
+// before c.FetchSince.
+func (f *gitlabForge) FetchPullRequests(c *Context) ([]*PullRequest, []*PullRequest, error) {
+	project := url.QueryEscape(c.Repo)
+	reqURL := fmt.Sprintf("%sprojects/%s/merge_requests?state=all&order_by=updated_at&sort=desc", gitlabAPI, project)
+	open, closed := []*PullRequest{}, []*PullRequest{}
+	var err error
+	var done bool
+	for len(reqURL) > 0 && !done {
+		fetched := []*gitlabMergeRequest{}
+		reqURL, err = fetchURLCached(c, reqURL, &fetched)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, mr := range fetched {
+			pr := mr.toPullRequest()
+			pr.URL = fmt.Sprintf("%sprojects/%s/merge_requests/%d", gitlabAPI, project, mr.IID)
+			if pr.State == "open" {
+				t, err := time.Parse(time.RFC3339, pr.CreatedAt)
+				if err != nil {
+					return nil, nil, err
+				}
+				if c.FetchSince.Before(t) {
+					open = append(open, pr)
+				}
+				continue
+			}
+			date := pr.MergedAt
+			if date == "" {
+				date = pr.ClosedAt
+			}
+			t, err := time.Parse(time.RFC3339, date)
+			if err != nil {
+				return nil, nil, err
+			}
+			if t.Before(c.FetchSince) {
+				done = true
+				break
+			}
+			closed = append(closed, pr)
+		}
+	}
+	return open, closed, nil
+}
+
+// FetchDetails fetches the files changed by a GitLab merge request.
+func (f *gitlabForge) FetchDetails(c *Context, pr *PullRequest) error {
+	var changes struct {
+		Changes []struct {
+			OldPath     string `json:"old_path"`
+			NewPath     string `json:"new_path"`
+			Diff        string `json:"diff"`
+			NewFile     bool   `json:"new_file"`
+			DeletedFile bool   `json:"deleted_file"`
+			RenamedFile bool   `json:"renamed_file"`
+		} `json:"changes"`
+	}
+	if _, err := fetchURLCached(c, pr.URL+"/changes", &changes); err != nil {
+		return err
+	}
+	files := make([]*File, 0, len(changes.Changes))
+	for _, ch := range changes.Changes {
+		status := "modified"
+		switch {
+		case ch.NewFile:
+			status = "added"
+		case ch.DeletedFile:
+			status = "removed"
+		case ch.RenamedFile:
+			status = "renamed"
+		}
+		files = append(files, &File{
+			Filename: ch.NewPath,
+			Status:   status,
+			Patch:    ch.Diff,
+		})
+	}
+	pr.Files = files
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cockroach/util/log"
+)
+
+// httpCacheDir is where cached responses are written, one file per
+// URL, keyed by its sha256. It's rooted under the user's cache
+// directory so `rm -rf` of the repo doesn't lose it.
+var httpCacheDir = filepath.Join(os.Getenv("HOME"), ".cache", "repo-digest")
+
+// cacheEntry is the on-disk representation of a cached response: the
+// raw body plus the validators needed to make a conditional request
+// next time.
+type cacheEntry struct {
+	URL          string `json:"url"`
+	Body         string `json:"body"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// httpCache is an on-disk, content-addressed cache of conditional-GET
+// responses. A closed, merged pull request's detail and files
+// endpoints never change, so replaying a 304 response avoids
+// re-downloading bodies we already have and keeps runs under GitHub's
+// rate limit. It's shared across QueryDetailedPullRequests's worker
+// pool, so the hit/miss counters are guarded by mu.
+type httpCache struct {
+	dir      string
+	disabled bool
+
+	mu           sync.Mutex
+	hits, misses int
+}
+
+func newHTTPCache(c *Context) *httpCache {
+	return &httpCache{dir: httpCacheDir, disabled: c.NoCache}
+}
+
+// sharedCache holds the single httpCache instance for the lifetime of
+// a digest run, so hit/miss counters accumulate across the open and
+// closed pull request passes, and across QueryDetailedPullRequests's
+// worker pool, instead of resetting each call.
+var (
+	sharedCacheOnce sync.Once
+	sharedCache     *httpCache
+)
+
+// getCache returns the shared cache for c, creating it on first use.
+// Safe to call concurrently from multiple workers.
+func getCache(c *Context) *httpCache {
+	sharedCacheOnce.Do(func() { sharedCache = newHTTPCache(c) })
+	return sharedCache
+}
+
+// fetchURLCached is a cache-aware drop-in for fetchURL: it issues a
+// conditional GET against url, replays the cached body on a 304, and
+// unmarshals the (possibly replayed) body into v. It returns the next
+// page URL parsed from the response's Link header, if any.
+func fetchURLCached(c *Context, url string, v interface{}) (string, error) {
+	cache := getCache(c)
+	entry, _ := cache.get(url)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+	cache.applyValidators(req, entry)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	rateLimiter(resp)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotModified:
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if ra := resp.Header.Get("Retry-After"); ra != "" {
+			if secs, err := strconv.Atoi(ra); err == nil {
+				return "", &rateLimitError{retryAfter: time.Duration(secs) * time.Second}
+			}
+		}
+		return "", fmt.Errorf("GET %s failed: %s", url, resp.Status)
+	default:
+		return "", fmt.Errorf("GET %s failed: %s", url, resp.Status)
+	}
+	body, err := cache.handleResponse(resp, url, entry, respBody)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return "", err
+	}
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+// rateLimitError indicates the forge asked us to back off for a
+// while (GitHub's secondary-abuse-rate-limit response, or a generic
+// 429), via its Retry-After header. Callers that want to retry
+// instead of failing the whole run should type-assert for it.
+type rateLimitError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.retryAfter)
+}
+
+// nextPageURL extracts the rel="next" target from an RFC 5988 Link
+// header, as returned by GitHub, GitLab and Gitea's paginated APIs.
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		if strings.TrimSpace(segments[1]) == `rel="next"` {
+			return strings.Trim(strings.TrimSpace(segments[0]), "<>")
+		}
+	}
+	return ""
+}
+
+func cachePath(dir, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, fmt.Sprintf("%x.json", sum))
+}
+
+// get returns the cached entry for url, if any.
+func (hc *httpCache) get(url string) (*cacheEntry, bool) {
+	if hc.disabled {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(cachePath(hc.dir, url))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// put writes entry to the cache.
+func (hc *httpCache) put(entry *cacheEntry) error {
+	if hc.disabled {
+		return nil
+	}
+	if err := os.MkdirAll(hc.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cachePath(hc.dir, entry.URL), data, 0644)
+}
+
+// applyValidators sets If-None-Match / If-Modified-Since on req from
+// a previously cached entry, if any.
+func (hc *httpCache) applyValidators(req *http.Request, entry *cacheEntry) {
+	if entry == nil {
+		return
+	}
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// handleResponse reconciles an HTTP response against the cache: a 304
+// replays the cached body, while a 200 that carries an ETag or
+// Last-Modified header stores the fresh body and those validators for
+// next time (a 200 without either is not cached). It returns the body
+// to unmarshal.
+func (hc *httpCache) handleResponse(resp *http.Response, url string, entry *cacheEntry, body []byte) ([]byte, error) {
+	if resp.StatusCode == http.StatusNotModified {
+		if entry == nil {
+			return nil, fmt.Errorf("got 304 for %s with no cached entry", url)
+		}
+		hc.mu.Lock()
+		hc.hits++
+		hc.mu.Unlock()
+		return []byte(entry.Body), nil
+	}
+	hc.mu.Lock()
+	hc.misses++
+	hc.mu.Unlock()
+	if etag := resp.Header.Get("ETag"); etag != "" || resp.Header.Get("Last-Modified") != "" {
+		if err := hc.put(&cacheEntry{
+			URL:          url,
+			Body:         string(body),
+			ETag:         etag,
+			LastModified: resp.Header.Get("Last-Modified"),
+		}); err != nil {
+			log.Warningf("failed to cache %s: %s", url, err)
+		}
+	}
+	return body, nil
+}
+
+// printStats prints the cache hit/miss counters alongside the
+// existing progress line.
+func (hc *httpCache) printStats() {
+	hc.mu.Lock()
+	hits, misses := hc.hits, hc.misses
+	hc.mu.Unlock()
+	fmt.Printf(" (cache: %d hit, %d miss)", hits, misses)
+}
+
+// rateLimiter sleeps as needed to honor GitHub's X-RateLimit-Remaining
+// / X-RateLimit-Reset headers, so a long run doesn't get cut off by a
+// 403 partway through.
+func rateLimiter(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining > 10 {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	wait := time.Until(time.Unix(reset, 0))
+	if wait > 0 {
+		log.Infof("approaching rate limit (%d remaining); sleeping %s until reset", remaining, wait)
+		time.Sleep(wait)
+	}
+}
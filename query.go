@@ -22,6 +22,7 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cockroachdb/cockroach/util/log"
@@ -30,10 +31,6 @@ import (
 // TODO(spencer): combine this code with the code in stargazers
 //   for a single utility.
 
-const (
-	githubAPI = "https://api.github.com/"
-)
-
 const (
 	// tinyPR threshold of additions and deletions.
 	tinyPR = 20
@@ -50,15 +47,6 @@ var ignoreRegexp = []*regexp.Regexp{
 	regexp.MustCompile(`.*\.css`),
 }
 
-func skipFile(f string) bool {
-	for _, ire := range ignoreRegexp {
-		if ire.MatchString(f) {
-			return true
-		}
-	}
-	return false
-}
-
 type User struct {
 	Login            string `json:"login"`
 	ID               int    `json:"id"`
@@ -174,6 +162,21 @@ type PullRequest struct {
 	ChangedFiles       int    `json:"changed_files"`
 
 	Files []*File `json:"-"`
+
+	// Trailers holds conventional commit trailers (Signed-off-by:,
+	// Reviewed-by:, Fixes:, Release-note:, Co-authored-by:) parsed
+	// from the merge commit message, keyed by trailer name.
+	Trailers map[string][]string `json:"trailers,omitempty"`
+	// Labels holds the forge's issue/PR labels, e.g. "do-not-merge",
+	// "release-note".
+	Labels []string `json:"labels,omitempty"`
+	// Tags holds repo-digest's own classification tags, seeded from
+	// the owning RepoConfig and available for downstream grouping.
+	Tags []string `json:"tags,omitempty"`
+
+	// thresh holds the size thresholds Class should use, set from the
+	// RepoConfig for this PR's repo when it's fetched.
+	thresh thresholds
 }
 
 // TotalChanges returns total of additions and deletions.
@@ -218,15 +221,21 @@ func (pr *PullRequest) Subdirectories() []*Subdirectory {
 }
 
 // Class returns one of "tiny", "small", "medium" or "large" depending
-// on the total number of changes in the pull request.
+// on the total number of changes in the pull request, measured
+// against pr.thresh (the repo's configured thresholds, or the
+// tinyPR...largePR defaults if none were set).
 func (pr *PullRequest) Class() string {
-	if tc := pr.TotalChanges(); tc < tinyPR {
+	t := pr.thresh
+	if t == (thresholds{}) {
+		t = defaultThresholds
+	}
+	if tc := pr.TotalChanges(); tc < t.tiny {
 		return "&#9679;"
-	} else if tc < smallPR {
+	} else if tc < t.small {
 		return "&#9679;&#9679;"
-	} else if tc < mediumPR {
+	} else if tc < t.medium {
 		return "&#9679;&#9679;&#9679;"
-	} else if tc < largePR {
+	} else if tc < t.large {
 		return "&#9679;&#9679;&#9679;&#9679;"
 	}
 	return "&#9679;&#9679;&#9679;&#9679;&#9679;"
@@ -252,96 +261,136 @@ func (pr *PullRequest) ClosedAtStr() string {
 	return t.Local().Format("Mon Jan _2 15:04:05")
 }
 
-// Queries pull requests for the repository. Returns a slice each for
-// open and closed pull requests.
+// Query queries pull requests for the repository using the Forge
+// selected for c (see NewForge). Returns a slice each for open and
+// closed pull requests.
 func Query(c *Context) ([]*PullRequest, []*PullRequest, error) {
-	open, closed, err := QueryPullRequests(c)
+	forge, err := NewForge(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc := c.Config.RepoConfig(c.Repo)
+	open, closed, err := QueryPullRequests(c, forge)
 	if err != nil {
 		return nil, nil, err
 	}
-	if err := QueryDetailedPullRequests(c, open); err != nil {
+	for _, pr := range open {
+		pr.thresh = rc.sizeThresholds()
+		pr.Tags = append(pr.Tags, rc.Tags...)
+	}
+	for _, pr := range closed {
+		pr.thresh = rc.sizeThresholds()
+		pr.Tags = append(pr.Tags, rc.Tags...)
+	}
+	if err := QueryDetailedPullRequests(c, forge, rc, open); err != nil {
 		return nil, nil, err
 	}
-	if err := QueryDetailedPullRequests(c, closed); err != nil {
+	if err := QueryDetailedPullRequests(c, forge, rc, closed); err != nil {
 		return nil, nil, err
 	}
 	return open, closed, nil
 }
 
 // QueryPullRequests queries all pull requests from the repo or a
-// day's worth, whichever is greater.
-func QueryPullRequests(c *Context) ([]*PullRequest, []*PullRequest, error) {
+// day's worth, whichever is greater, via forge.
+func QueryPullRequests(c *Context, forge Forge) ([]*PullRequest, []*PullRequest, error) {
 	log.Infof("querying pull requests from %s opened or closed after %s", c.Repo, c.FetchSince.Format(time.RFC3339))
-	url := fmt.Sprintf("%srepos/%s/pulls?state=all&sort=updated&direction=desc", githubAPI, c.Repo)
-	open, closed := []*PullRequest{}, []*PullRequest{}
-	total := 0
-	var err error
-	var done bool
-	fmt.Printf("*** 0 open 0 closed, 0 total pull requests")
-	for len(url) > 0 && !done {
-		fetched := []*PullRequest{}
-		url, err = fetchURL(c, url, &fetched)
-		if err != nil {
-			return nil, nil, err
-		}
-		total += len(fetched)
-		for _, pr := range fetched {
-			var date string
-			switch pr.State {
-			case "open":
-				date = pr.CreatedAt
-			case "closed":
-				date = pr.ClosedAt
-			default:
-				continue
-			}
-			t, err := time.Parse(time.RFC3339, date)
-			if err != nil {
-				return nil, nil, err
-			}
-			if pr.State == "open" {
-				if c.FetchSince.Before(t) {
-					open = append(open, pr)
-				}
-			} else {
-				if t.Before(c.FetchSince) {
-					done = true
-					break
-				}
-				closed = append(closed, pr)
-			}
-		}
-		fmt.Printf("\r*** %s open %s closed %s total pull requests", format(len(open)), format(len(closed)), format(total))
+	fmt.Printf("*** 0 open 0 closed pull requests")
+	open, closed, err := forge.FetchPullRequests(c)
+	if err != nil {
+		return nil, nil, err
 	}
-	fmt.Printf("\n")
+	fmt.Printf("\r*** %s open %s closed pull requests\n", format(len(open)), format(len(closed)))
 	return open, closed, nil
 }
 
 // QueryDetailedPullRequests queries detailed info on each pull request
-// in the provided slice.
-func QueryDetailedPullRequests(c *Context, prs []*PullRequest) error {
+// in the provided slice, fanning the work out across a pool of
+// c.Concurrency workers (default defaultConcurrency) and filtering
+// out files ignored by rc. Completed PRs are streamed to
+// c.CheckpointFile as they finish, so a crashed or Ctrl-C'd run can
+// resume without re-fetching them, restoring their Files, Labels and
+// Trailers from the checkpoint instead of leaving them empty;
+// responses for immutable, closed pull requests are additionally
+// served from the on-disk httpCache on subsequent runs.
+func QueryDetailedPullRequests(c *Context, forge Forge, rc *RepoConfig, prs []*PullRequest) error {
 	log.Infof("querying detailed info for each of %s pull requests...", format(len(prs)))
+	checkpoint, err := loadCheckpoint(c.CheckpointFile)
+	if err != nil {
+		return err
+	}
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	work := make(chan *PullRequest)
+	errs := make(chan error, concurrency)
+	// done is closed the moment a worker gives up (or all of them
+	// finish normally), so the feeder loop below stops sending into
+	// work instead of blocking forever once no worker is left to
+	// receive.
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(done) }) }
+
+	var progress progressCounter
+	var checkpointMu sync.Mutex
+	var wg sync.WaitGroup
+
 	fmt.Printf("*** detailed info for 0 pull requests")
-	for i, pr := range prs {
-		// Fetch detailed pull request info.
-		if _, err := fetchURL(c, pr.URL, pr); err != nil {
-			return err
-		}
-		// Fetch files changed by pull request.
-		if _, err := fetchURL(c, pr.URL+"/files", &pr.Files); err != nil {
-			return err
-		}
-		// Remove files we're supposed to ignore.
-		newFiles := []*File{}
-		for _, f := range pr.Files {
-			if !skipFile(f.Filename) {
-				newFiles = append(newFiles, f)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pr := range work {
+				if rec := checkpoint[checkpointKey(pr.Number, pr.Updatedat)]; rec != nil {
+					rec.restore(pr)
+					progress.increment()
+					continue
+				}
+				if err := fetchDetailsWithRetry(c, forge, pr); err != nil {
+					errs <- err
+					stop()
+					return
+				}
+				// Remove files we're supposed to ignore.
+				newFiles := []*File{}
+				for _, f := range pr.Files {
+					if !skipFile(rc, f.Filename) {
+						newFiles = append(newFiles, f)
+					}
+				}
+				pr.Files = newFiles
+				if err := appendCheckpoint(c.CheckpointFile, &checkpointMu, pr); err != nil {
+					errs <- err
+					stop()
+					return
+				}
+				progress.increment()
 			}
+		}()
+	}
+feed:
+	for _, pr := range prs {
+		select {
+		case work <- pr:
+		case <-done:
+			break feed
 		}
-		pr.Files = newFiles
-		fmt.Printf("\r*** detailed info for %s pull requests", format(i+1))
 	}
+	close(work)
+	wg.Wait()
+	stop() // no-op if a worker already stopped; ensures done is always closed
+	close(errs)
+	getCache(c).printStats()
 	fmt.Printf("\n")
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
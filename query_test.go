@@ -0,0 +1,71 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// countingForge is a Forge stub that counts FetchDetails calls, so
+// tests can assert a checkpointed PR was skipped rather than
+// re-fetched.
+type countingForge struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *countingForge) FetchPullRequests(c *Context) ([]*PullRequest, []*PullRequest, error) {
+	return nil, nil, nil
+}
+
+func (f *countingForge) FetchDetails(c *Context, pr *PullRequest) error {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	pr.Files = []*File{{Filename: "fetched.go"}}
+	return nil
+}
+
+func TestQueryDetailedPullRequestsResumesFromCheckpoint(t *testing.T) {
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	pr := &PullRequest{Number: 1, Updatedat: "2016-03-01T00:00:00Z"}
+
+	forge := &countingForge{}
+	c := &Context{CheckpointFile: checkpointFile, Concurrency: 1}
+	if err := QueryDetailedPullRequests(c, forge, &RepoConfig{}, []*PullRequest{pr}); err != nil {
+		t.Fatalf("first run: QueryDetailedPullRequests returned error: %s", err)
+	}
+	if forge.calls != 1 {
+		t.Fatalf("first run: forge.calls = %d, want 1", forge.calls)
+	}
+
+	// Re-run against a fresh PullRequest with the same number and
+	// updated_at: it should be restored from the checkpoint rather
+	// than refetched.
+	resumed := &PullRequest{Number: 1, Updatedat: "2016-03-01T00:00:00Z"}
+	if err := QueryDetailedPullRequests(c, forge, &RepoConfig{}, []*PullRequest{resumed}); err != nil {
+		t.Fatalf("second run: QueryDetailedPullRequests returned error: %s", err)
+	}
+	if forge.calls != 1 {
+		t.Fatalf("second run: forge.calls = %d, want still 1 (should have been skipped)", forge.calls)
+	}
+	if len(resumed.Files) != 1 || resumed.Files[0].Filename != "fetched.go" {
+		t.Fatalf("resumed.Files = %+v, want restored from checkpoint", resumed.Files)
+	}
+}
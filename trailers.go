@@ -0,0 +1,82 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// trailerKeys lists the conventional commit trailers repo-digest
+// knows to pull out of a merge commit message.
+var trailerKeys = []string{
+	"Signed-off-by",
+	"Reviewed-by",
+	"Fixes",
+	"Release-note",
+	"Co-authored-by",
+}
+
+// trailerLine matches a single "Key: value" trailer line.
+var trailerLine = regexp.MustCompile(`^([A-Za-z][A-Za-z-]*):\s*(.+)$`)
+
+// parseTrailers scans a commit message for conventional trailers
+// (Signed-off-by:, Reviewed-by:, Fixes: #NNN, Release-note:,
+// Co-authored-by:) and returns them keyed by trailer name, preserving
+// the order multiple values of the same key appeared in.
+func parseTrailers(message string) map[string][]string {
+	trailers := map[string][]string{}
+	for _, line := range strings.Split(message, "\n") {
+		m := trailerLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		key := canonicalTrailerKey(m[1])
+		if key == "" {
+			continue
+		}
+		trailers[key] = append(trailers[key], strings.TrimSpace(m[2]))
+	}
+	return trailers
+}
+
+// canonicalTrailerKey returns key's canonical form from trailerKeys
+// (matched case-insensitively), or "" if key isn't one we track.
+func canonicalTrailerKey(key string) string {
+	for _, tk := range trailerKeys {
+		if strings.EqualFold(tk, key) {
+			return tk
+		}
+	}
+	return ""
+}
+
+// emailDomain extracts the domain from a trailer value of the form
+// "Name <user@example.com>", or "" if none is present.
+func emailDomain(value string) string {
+	start := strings.IndexByte(value, '<')
+	end := strings.IndexByte(value, '>')
+	if start < 0 || end < 0 || end < start {
+		return ""
+	}
+	email := value[start+1 : end]
+	at := strings.IndexByte(email, '@')
+	if at < 0 {
+		return ""
+	}
+	return email[at+1:]
+}
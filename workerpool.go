@@ -0,0 +1,156 @@
+// Copyright 2016 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// Author: Spencer Kimball (spencer.kimball@gmail.com)
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cockroach/util/log"
+)
+
+// defaultConcurrency is how many pull requests QueryDetailedPullRequests
+// fetches details for at once when c.Concurrency is unset.
+const defaultConcurrency = 8
+
+// maxRetries bounds how many times fetchDetailsWithRetry will back
+// off for a rate-limited forge before giving up on a single PR.
+const maxRetries = 5
+
+// checkpointRecord is a single line of the resumable checkpoint file:
+// one PR's fetched detail fields, keyed by number and last-seen
+// updated_at, so a crashed or Ctrl-C'd run can skip PRs it's already
+// fetched details for without losing their data.
+type checkpointRecord struct {
+	Number    int                 `json:"number"`
+	UpdatedAt string              `json:"updated_at"`
+	Files     []*File             `json:"files"`
+	Labels    []string            `json:"labels,omitempty"`
+	Trailers  map[string][]string `json:"trailers,omitempty"`
+}
+
+func checkpointKey(number int, updatedAt string) string {
+	return fmt.Sprintf("%d@%s", number, updatedAt)
+}
+
+// loadCheckpoint reads the checkpoint file at path, if any, into a map
+// of "number@updated_at" keys to the detail fields already fetched
+// for that PR. An empty path disables checkpointing.
+func loadCheckpoint(path string) (map[string]*checkpointRecord, error) {
+	done := map[string]*checkpointRecord{}
+	if path == "" {
+		return done, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec checkpointRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		done[checkpointKey(rec.Number, rec.UpdatedAt)] = &rec
+	}
+	return done, scanner.Err()
+}
+
+// appendCheckpoint records pr's detail fields as completed in the
+// checkpoint file at path, creating it if necessary. mu serializes
+// appends from the worker pool's concurrent goroutines.
+func appendCheckpoint(path string, mu *sync.Mutex, pr *PullRequest) error {
+	if path == "" {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(checkpointRecord{
+		Number:    pr.Number,
+		UpdatedAt: pr.Updatedat,
+		Files:     pr.Files,
+		Labels:    pr.Labels,
+		Trailers:  pr.Trailers,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// restore copies rec's fetched detail fields onto pr, so resuming
+// from a checkpoint doesn't lose the work already done for it.
+func (rec *checkpointRecord) restore(pr *PullRequest) {
+	pr.Files = rec.Files
+	pr.Labels = rec.Labels
+	pr.Trailers = rec.Trailers
+}
+
+// progressCounter aggregates completions from every worker into the
+// same single-line, \r-updated counter QueryDetailedPullRequests has
+// always printed.
+type progressCounter struct {
+	mu   sync.Mutex
+	done int
+}
+
+func (p *progressCounter) increment() {
+	p.mu.Lock()
+	p.done++
+	n := p.done
+	p.mu.Unlock()
+	fmt.Printf("\r*** detailed info for %s pull requests", format(n))
+}
+
+// fetchDetailsWithRetry calls forge.FetchDetails, retrying when it
+// reports a rate-limit error (GitHub's secondary-abuse-rate-limit
+// Retry-After, or a generic 429). Each retry sleeps for the error's
+// Retry-After duration, or, when that is zero, for an exponential
+// backoff that starts at one second and doubles per attempt.
+func fetchDetailsWithRetry(c *Context, forge Forge, pr *PullRequest) error {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := forge.FetchDetails(c, pr)
+		rlErr, ok := err.(*rateLimitError)
+		if !ok {
+			return err
+		}
+		if attempt >= maxRetries {
+			return fmt.Errorf("giving up on %s after %d retries: %s", pr.URL, attempt, rlErr)
+		}
+		wait := rlErr.retryAfter
+		if wait <= 0 {
+			wait = backoff
+		}
+		log.Infof("rate limited fetching %s, sleeping %s (attempt %d)", pr.URL, wait, attempt+1)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}